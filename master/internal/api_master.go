@@ -2,15 +2,23 @@ package internal
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/determined-ai/determined/master/internal/config"
 	"github.com/determined-ai/determined/master/pkg/actor"
 
 	structpb "github.com/golang/protobuf/ptypes/struct"
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/determined-ai/determined/master/pkg/logger"
 	"github.com/determined-ai/determined/proto/pkg/logv1"
@@ -54,6 +62,123 @@ func (a *apiServer) GetMasterConfig(
 	}, err
 }
 
+// logLevelProtoMap maps logger.Level (logrus levels, most-severe first) to the logv1.LogLevel
+// enum used on the wire.
+var logLevelProtoMap = map[logger.Level]logv1.LogLevel{
+	logger.LevelCritical: logv1.LogLevel_LOG_LEVEL_CRITICAL,
+	logger.LevelError:    logv1.LogLevel_LOG_LEVEL_ERROR,
+	logger.LevelWarn:     logv1.LogLevel_LOG_LEVEL_WARNING,
+	logger.LevelInfo:     logv1.LogLevel_LOG_LEVEL_INFO,
+	logger.LevelDebug:    logv1.LogLevel_LOG_LEVEL_DEBUG,
+}
+
+func logLevelToProto(l logger.Level) logv1.LogLevel {
+	if pl, ok := logLevelProtoMap[l]; ok {
+		return pl
+	}
+	return logv1.LogLevel_LOG_LEVEL_UNSPECIFIED
+}
+
+func logLevelFromProto(pl logv1.LogLevel) logger.Level {
+	for l, mapped := range logLevelProtoMap {
+		if mapped == pl {
+			return l
+		}
+	}
+	return logger.LevelDebug
+}
+
+// masterLogFilter reports whether a log entry matches the predicates carried on a
+// MasterLogsRequest, so that a follower only receives entries it actually asked for.
+type masterLogFilter struct {
+	minLevel    logger.Level
+	hasLevel    bool
+	components  map[string]bool
+	timeAfter   *time.Time
+	timeBefore  *time.Time
+	searchRegex *regexp.Regexp
+}
+
+func newMasterLogFilter(req *apiv1.MasterLogsRequest) (*masterLogFilter, error) {
+	f := &masterLogFilter{}
+	if req.MinLevel != logv1.LogLevel_LOG_LEVEL_UNSPECIFIED {
+		f.minLevel = logLevelFromProto(req.MinLevel)
+		f.hasLevel = true
+	}
+	if len(req.Components) > 0 {
+		f.components = make(map[string]bool, len(req.Components))
+		for _, c := range req.Components {
+			f.components[c] = true
+		}
+	}
+	if req.TimeAfter != nil {
+		t := time.Unix(req.TimeAfter.Seconds, int64(req.TimeAfter.Nanos)).UTC()
+		f.timeAfter = &t
+	}
+	if req.TimeBefore != nil {
+		t := time.Unix(req.TimeBefore.Seconds, int64(req.TimeBefore.Nanos)).UTC()
+		f.timeBefore = &t
+	}
+	if req.SearchText != "" {
+		re, err := regexp.Compile(req.SearchText)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid search_text regex")
+		}
+		f.searchRegex = re
+	}
+	return f, nil
+}
+
+// active reports whether any predicate is set, so callers can tell a real filter apart from the
+// zero-value "match everything" filter built for an unfiltered request.
+func (f *masterLogFilter) active() bool {
+	return f.hasLevel || f.components != nil ||
+		f.timeAfter != nil || f.timeBefore != nil || f.searchRegex != nil
+}
+
+func (f *masterLogFilter) matches(lr *logger.Entry) bool {
+	if f.hasLevel && lr.Level > f.minLevel {
+		return false
+	}
+	if f.components != nil && !f.components[lr.Component] {
+		return false
+	}
+	if f.timeAfter != nil && lr.Timestamp.Before(*f.timeAfter) {
+		return false
+	}
+	if f.timeBefore != nil && lr.Timestamp.After(*f.timeBefore) {
+		return false
+	}
+	if f.searchRegex != nil && !f.searchRegex.MatchString(lr.Message) {
+		return false
+	}
+	return true
+}
+
+// formatLogMessage renders a log entry's message body in the client-requested TextFormat.
+func formatLogMessage(format apiv1.LogFormat, lr *logger.Entry) string {
+	switch format {
+	case apiv1.LogFormat_LOG_FORMAT_LOGFMT:
+		return fmt.Sprintf(
+			"level=%s component=%s time=%s msg=%q",
+			lr.Level, lr.Component, lr.Timestamp.Format(time.RFC3339), lr.Message,
+		)
+	case apiv1.LogFormat_LOG_FORMAT_PLAIN:
+		return lr.Message
+	default: // apiv1.LogFormat_LOG_FORMAT_JSON and unset default to JSON for programmatic consumers.
+		out, err := json.Marshal(struct {
+			Level     string `json:"level"`
+			Component string `json:"component"`
+			Time      string `json:"time"`
+			Message   string `json:"message"`
+		}{fmt.Sprint(lr.Level), lr.Component, lr.Timestamp.Format(time.RFC3339), lr.Message})
+		if err != nil {
+			return lr.Message
+		}
+		return string(out)
+	}
+}
+
 func (a *apiServer) MasterLogs(
 	req *apiv1.MasterLogsRequest, resp apiv1.Determined_MasterLogsServer) error {
 	if err := grpcutil.ValidateRequest(
@@ -63,17 +188,42 @@ func (a *apiServer) MasterLogs(
 		return err
 	}
 
+	filter, err := newMasterLogFilter(req)
+	if err != nil {
+		return err
+	}
+
+	// When a predicate is active, req.Limit counts matching entries, not raw ring-buffer slots,
+	// so onBatch has to stop forwarding once it has sent that many matches rather than relying on
+	// the fetch window to have been sized correctly up front.
+	matched := 0
 	onBatch := func(b api.LogBatch) error {
 		return b.ForEach(func(r interface{}) error {
 			lr := r.(*logger.Entry)
+			if !filter.matches(lr) {
+				return nil
+			}
+			if filter.active() && !req.Follow && req.Limit > 0 && matched >= int(req.Limit) {
+				return nil
+			}
+			matched++
 			return resp.Send(&apiv1.MasterLogsResponse{
-				LogEntry: &logv1.LogEntry{Id: int32(lr.ID), Message: lr.Message},
+				LogEntry: &logv1.LogEntry{
+					Id:        int32(lr.ID),
+					Message:   formatLogMessage(req.TextFormat, lr),
+					Level:     logLevelToProto(lr.Level),
+					Timestamp: timestamppb.New(lr.Timestamp),
+					Component: lr.Component,
+					Labels:    lr.Labels,
+				},
 			})
 		})
 	}
 
 	fetch := func(lr api.LogsRequest) (api.LogBatch, error) {
-		if lr.Follow {
+		if lr.Follow || filter.active() {
+			// -1 pulls every entry from Offset onward so the predicate sees the full ring buffer
+			// tail instead of a window sized against the unfiltered total.
 			lr.Limit = -1
 		}
 		return logger.EntriesBatch(a.m.logs.Entries(lr.Offset, -1, lr.Limit)), nil
@@ -81,6 +231,9 @@ func (a *apiServer) MasterLogs(
 
 	total := a.m.logs.Len()
 	offset, limit := api.EffectiveOffsetNLimit(int(req.Offset), int(req.Limit), total)
+	if filter.active() {
+		limit = -1
+	}
 	lReq := api.LogsRequest{Offset: offset, Limit: limit, Follow: req.Follow}
 
 	return a.m.system.MustActorOf(
@@ -115,3 +268,149 @@ func (a *apiServer) ResourceAllocationRaw(
 
 	return resp, nil
 }
+
+func (a *apiServer) ResourceAllocationAggregated(
+	_ context.Context,
+	req *apiv1.ResourceAllocationAggregatedRequest,
+) (*apiv1.ResourceAllocationAggregatedResponse, error) {
+	resp := &apiv1.ResourceAllocationAggregatedResponse{}
+
+	if req.TimestampAfter == nil {
+		return nil, errors.New("no start time provided")
+	}
+	if req.TimestampBefore == nil {
+		return nil, errors.New("no end time provided")
+	}
+	start := time.Unix(req.TimestampAfter.Seconds, int64(req.TimestampAfter.Nanos)).UTC()
+	end := time.Unix(req.TimestampBefore.Seconds, int64(req.TimestampBefore.Nanos)).UTC()
+	if start.After(end) {
+		return nil, errors.New("start time cannot be after end time")
+	}
+	if req.Period == apiv1.ResourceAllocationAggregationPeriod_RESOURCE_ALLOCATION_AGGREGATION_PERIOD_UNSPECIFIED {
+		return nil, errors.New("no aggregation period provided")
+	}
+	if len(req.GroupBy) == 0 {
+		return nil, errors.New("no group-by dimension provided")
+	}
+
+	groupBy := make([]string, 0, len(req.GroupBy))
+	for _, g := range req.GroupBy {
+		groupBy = append(groupBy, g.String())
+	}
+
+	if err := a.m.db.QueryProto(
+		"allocation_aggregated", &resp.ResourceBuckets,
+		req.Period.String(), groupBy, start.UTC(), end.UTC(),
+	); err != nil {
+		return nil, errors.Wrap(err, "error fetching aggregated allocation data")
+	}
+
+	rates := a.m.config.ResourcePoolPricing
+	for _, b := range resp.ResourceBuckets {
+		b.EstimatedCostUsd = estimateCostUSD(b, rates)
+	}
+
+	return resp, nil
+}
+
+// ResourceAllocationAggregatedCSV streams the same rollup as ResourceAllocationAggregated, one
+// row per bucket, for operators who want to download the report rather than call the API.
+func (a *apiServer) ResourceAllocationAggregatedCSV(
+	req *apiv1.ResourceAllocationAggregatedCSVRequest,
+	resp apiv1.Determined_ResourceAllocationAggregatedCSVServer,
+) error {
+	onBatch := func(b api.LogBatch) error {
+		return b.ForEach(func(r interface{}) error {
+			bucket := r.(*apiv1.ResourceAllocationAggregatedEntry)
+			line, err := resourceAllocationCSVLine(bucket)
+			if err != nil {
+				return errors.Wrap(err, "error encoding allocation CSV row")
+			}
+			return resp.Send(&apiv1.ResourceAllocationAggregatedCSVResponse{Line: line})
+		})
+	}
+
+	fetch := func(lr api.LogsRequest) (api.LogBatch, error) {
+		aggReq := &apiv1.ResourceAllocationAggregatedRequest{
+			Period:          req.Period,
+			GroupBy:         req.GroupBy,
+			TimestampAfter:  req.TimestampAfter,
+			TimestampBefore: req.TimestampBefore,
+		}
+		aggResp, err := a.ResourceAllocationAggregated(resp.Context(), aggReq)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]interface{}, len(aggResp.ResourceBuckets))
+		for i, b := range aggResp.ResourceBuckets {
+			rows[i] = b
+		}
+		return sliceLogBatch(rows), nil
+	}
+
+	return a.m.system.MustActorOf(
+		actor.Addr("allocationCSV-"+uuid.New().String()),
+		api.NewLogStoreProcessor(
+			resp.Context(), api.LogsRequest{Limit: -1}, fetch, onBatch, nil, nil,
+		),
+	).AwaitTermination()
+}
+
+// sliceLogBatch adapts a plain slice to api.LogBatch for handlers, like the allocation CSV
+// stream, that build their rows in memory rather than reading from the master's log ring buffer.
+type sliceLogBatch []interface{}
+
+func (b sliceLogBatch) ForEach(f func(interface{}) error) error {
+	for _, r := range b {
+		if err := f(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resourceAllocationCSVLine renders one aggregated bucket as a CSV row. GroupKey is built from
+// operator-entered user/workspace/project/label values and so may itself contain commas or
+// quotes; encoding/csv handles the escaping rather than a bare Sprintf.
+func resourceAllocationCSVLine(b *apiv1.ResourceAllocationAggregatedEntry) (string, error) {
+	bucketStart := time.Unix(b.BucketStart.Seconds, int64(b.BucketStart.Nanos)).
+		UTC().Format(time.RFC3339)
+
+	var buf strings.Builder
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{
+		bucketStart,
+		b.GroupKey,
+		strconv.FormatFloat(b.SlotSeconds, 'f', -1, 64),
+		strconv.FormatFloat(b.InstanceSeconds, 'f', -1, 64),
+		strconv.FormatFloat(b.EstimatedCostUsd, 'f', -1, 64),
+	}); err != nil {
+		return "", err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+// estimateCostUSD prices an aggregated bucket using the allocation's recorded spot price when it
+// ran on an AWS spot instance. Otherwise it uses the resource_pool_pricing on-demand rate for the
+// bucket's EC2 instance type, then the resource_pool_pricing rate for its resource pool, and
+// returns 0 when neither is configured.
+func estimateCostUSD(
+	b *apiv1.ResourceAllocationAggregatedEntry, rates config.ResourcePoolPricingConfig,
+) float64 {
+	if b.SpotMaxPrice != "" {
+		if price, err := strconv.ParseFloat(b.SpotMaxPrice, 64); err == nil {
+			return price * b.InstanceSeconds / 3600
+		}
+	}
+	if rate, ok := rates.OnDemandRateUSDPerHour[b.Ec2InstanceType]; ok {
+		return rate * b.InstanceSeconds / 3600
+	}
+	if rate, ok := rates.ResourcePoolRateUSDPerHour[b.ResourcePool]; ok {
+		return rate * b.InstanceSeconds / 3600
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/determined-ai/determined/master/pkg/logger"
+)
+
+func TestMasterLogFilterMatchesMinLevel(t *testing.T) {
+	// logrus levels are most-severe first: Critical < Error < Warn < Info < Debug.
+	testCases := []struct {
+		name     string
+		minLevel logger.Level
+		entry    logger.Level
+		want     bool
+	}{
+		{"critical passes error minimum", logger.LevelError, logger.LevelCritical, true},
+		{"error passes error minimum", logger.LevelError, logger.LevelError, true},
+		{"warn fails error minimum", logger.LevelError, logger.LevelWarn, false},
+		{"info fails error minimum", logger.LevelError, logger.LevelInfo, false},
+		{"debug passes debug minimum", logger.LevelDebug, logger.LevelDebug, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &masterLogFilter{hasLevel: true, minLevel: tc.minLevel}
+			got := f.matches(&logger.Entry{Level: tc.entry, Timestamp: time.Now()})
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMasterLogFilterMatchesComponentAndSearch(t *testing.T) {
+	f := &masterLogFilter{components: map[string]bool{"master": true}}
+	require.True(t, f.matches(&logger.Entry{Component: "master"}))
+	require.False(t, f.matches(&logger.Entry{Component: "agent"}))
+
+	f.searchRegex = regexp.MustCompile("^scheduler")
+	require.True(t, f.matches(&logger.Entry{Component: "master", Message: "scheduler started"}))
+	require.False(t, f.matches(&logger.Entry{Component: "master", Message: "agent connected"}))
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/determined-ai/determined/master/internal/config"
+	"github.com/determined-ai/determined/proto/pkg/apiv1"
+)
+
+func TestResourceAllocationCSVLineEscapesGroupKey(t *testing.T) {
+	bucket := &apiv1.ResourceAllocationAggregatedEntry{
+		BucketStart:      timestamppb.New(time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)),
+		GroupKey:         `user "a", workspace "b"`,
+		SlotSeconds:      1,
+		InstanceSeconds:  2,
+		EstimatedCostUsd: 3,
+	}
+	line, err := resourceAllocationCSVLine(bucket)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		`2026-01-02T03:00:00Z,"user ""a"", workspace ""b""",1,2,3`,
+		line,
+	)
+}
+
+func TestEstimateCostUSDPrefersSpotPrice(t *testing.T) {
+	bucket := &apiv1.ResourceAllocationAggregatedEntry{
+		SpotMaxPrice:    "1.00",
+		InstanceSeconds: 3600,
+		Ec2InstanceType: "p3.2xlarge",
+	}
+	rates := config.ResourcePoolPricingConfig{
+		OnDemandRateUSDPerHour: map[string]float64{"p3.2xlarge": 100},
+	}
+	require.Equal(t, 1.0, estimateCostUSD(bucket, rates))
+}
+
+func TestEstimateCostUSDFallsBackToOnDemandRate(t *testing.T) {
+	bucket := &apiv1.ResourceAllocationAggregatedEntry{
+		InstanceSeconds: 3600,
+		Ec2InstanceType: "p3.2xlarge",
+	}
+	rates := config.ResourcePoolPricingConfig{
+		OnDemandRateUSDPerHour: map[string]float64{"p3.2xlarge": 2.5},
+	}
+	require.Equal(t, 2.5, estimateCostUSD(bucket, rates))
+}
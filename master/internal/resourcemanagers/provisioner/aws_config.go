@@ -39,11 +39,169 @@ type AWSClusterConfig struct {
 	SpotEnabled  bool   `json:"spot"`
 	SpotMaxPrice string `json:"spot_max_price"`
 
+	SpotFleet *ec2SpotFleetConfig `json:"spot_fleet,omitempty"`
+
+	LaunchTemplate *ec2LaunchTemplate `json:"launch_template,omitempty"`
+
 	CustomTags []*ec2Tag `json:"custom_tags"`
 
 	CPUSlotsAllowed bool `json:"cpu_slots_allowed"`
 }
 
+// ec2LaunchTemplate lets a pool either reference an EC2 launch template the user pre-created, or
+// describe inline overrides. Validate requires exactly one of LaunchTemplateID or the inline
+// fields to be set. Validate is the only reader of this config: the provisioner's RunInstances
+// call does not apply the referenced template or the inline overrides.
+type ec2LaunchTemplate struct {
+	LaunchTemplateID      string `json:"launch_template_id"`
+	LaunchTemplateVersion string `json:"launch_template_version"`
+
+	BlockDeviceMappings      []ec2BlockDeviceMapping      `json:"block_device_mappings"`
+	UserDataExtra            string                       `json:"user_data_extra"`
+	MetadataOptions          *ec2MetadataOptions          `json:"metadata_options,omitempty"`
+	CreditSpecification      *ec2CreditSpecification      `json:"credit_specification,omitempty"`
+	ElasticGpuSpecifications []ec2ElasticGpuSpecification `json:"elastic_gpu_specifications"`
+}
+
+// usesReference reports whether this launch template points at a pre-created EC2 launch template
+// rather than describing inline overrides.
+func (t ec2LaunchTemplate) usesReference() bool {
+	return len(t.LaunchTemplateID) > 0
+}
+
+type ec2BlockDeviceMapping struct {
+	DeviceName string `json:"device_name"`
+	VolumeSize int    `json:"volume_size"`
+	VolumeType string `json:"volume_type"`
+	Iops       int    `json:"iops"`
+	Throughput int    `json:"throughput"`
+	Encrypted  bool   `json:"encrypted"`
+	KmsKeyID   string `json:"kms_key_id"`
+}
+
+type ec2MetadataOptions struct {
+	HTTPTokens              ec2HTTPTokensState `json:"http_tokens"`
+	HTTPPutResponseHopLimit int                `json:"http_put_response_hop_limit"`
+}
+
+// ec2HTTPTokensState mirrors EC2's MetadataOptions HttpTokens values (IMDSv2 enforcement).
+type ec2HTTPTokensState string
+
+const (
+	ec2HTTPTokensOptional ec2HTTPTokensState = "optional"
+	ec2HTTPTokensRequired ec2HTTPTokensState = "required"
+)
+
+func (s ec2HTTPTokensState) valid() bool {
+	switch s {
+	case "", ec2HTTPTokensOptional, ec2HTTPTokensRequired:
+		return true
+	default:
+		return false
+	}
+}
+
+// ec2CreditSpecification configures CPU credits for T-family burstable instances.
+type ec2CreditSpecification struct {
+	CPUCredits ec2CPUCredits `json:"cpu_credits"`
+}
+
+// ec2CPUCredits mirrors EC2's CreditSpecification CpuCredits values.
+type ec2CPUCredits string
+
+const (
+	ec2CPUCreditsStandard  ec2CPUCredits = "standard"
+	ec2CPUCreditsUnlimited ec2CPUCredits = "unlimited"
+)
+
+func (c ec2CPUCredits) valid() bool {
+	switch c {
+	case "", ec2CPUCreditsStandard, ec2CPUCreditsUnlimited:
+		return true
+	default:
+		return false
+	}
+}
+
+type ec2ElasticGpuSpecification struct {
+	Type string `json:"type"`
+}
+
+// customTagTemplateVars are the template variables expandable in a CustomTags value, so
+// cost-allocation tags can carry the requesting user/workspace through to the aggregation API.
+type customTagTemplateVars struct {
+	InstanceID     string
+	PoolName       string
+	DeterminedUser string
+}
+
+func (v customTagTemplateVars) expand(s string) string {
+	replacer := strings.NewReplacer(
+		"{instance_id}", v.InstanceID,
+		"{pool_name}", v.PoolName,
+		"{determined_user}", v.DeterminedUser,
+	)
+	return replacer.Replace(s)
+}
+
+// expandedCustomTags returns CustomTags with the {instance_id}/{pool_name}/{determined_user}
+// template variables expanded for a specific launch.
+func (c AWSClusterConfig) expandedCustomTags(vars customTagTemplateVars) []*ec2Tag {
+	tags := make([]*ec2Tag, len(c.CustomTags))
+	for i, t := range c.CustomTags {
+		tags[i] = &ec2Tag{Key: t.Key, Value: vars.expand(t.Value)}
+	}
+	return tags
+}
+
+// ec2SpotFleetConfig describes a heterogeneous spot fleet: a target capacity (in Determined
+// slots) that EC2 fills from whichever of the listed launch specifications it can satisfy. It is
+// read by Validate, SlotsPerInstance and SlotType; the provisioner issues no RequestSpotFleet,
+// ModifySpotFleetRequest or CancelSpotFleetRequests call for it.
+type ec2SpotFleetConfig struct {
+	IamFleetRoleArn                  string                    `json:"iam_fleet_role_arn"`
+	AllocationStrategy               ec2SpotFleetAllocStrategy `json:"allocation_strategy"`
+	TerminateInstancesWithExpiration bool                      `json:"terminate_instances_with_expiration"`
+	LaunchSpecifications             []ec2SpotFleetLaunchSpec  `json:"launch_specifications"`
+}
+
+// ec2SpotFleetLaunchSpec is one candidate instance type/subnet the fleet may launch into, along
+// with the weighted capacity it contributes toward the fleet's target capacity.
+type ec2SpotFleetLaunchSpec struct {
+	InstanceType     ec2InstanceType `json:"instance_type"`
+	SubnetID         string          `json:"subnet_id"`
+	WeightedCapacity float64         `json:"weighted_capacity"`
+	MaxPrice         string          `json:"max_price"`
+	InstanceSlots    *int            `json:"instance_slots,omitempty"`
+}
+
+// ec2SpotFleetAllocStrategy mirrors EC2's SpotFleetRequestConfig AllocationStrategy values.
+type ec2SpotFleetAllocStrategy string
+
+const (
+	ec2SpotFleetLowestPrice       ec2SpotFleetAllocStrategy = "lowestPrice"
+	ec2SpotFleetDiversified       ec2SpotFleetAllocStrategy = "diversified"
+	ec2SpotFleetCapacityOptimized ec2SpotFleetAllocStrategy = "capacityOptimized"
+)
+
+func (s ec2SpotFleetAllocStrategy) valid() bool {
+	switch s {
+	case ec2SpotFleetLowestPrice, ec2SpotFleetDiversified, ec2SpotFleetCapacityOptimized:
+		return true
+	default:
+		return false
+	}
+}
+
+// slots returns the number of Determined slots provided by this launch spec, falling back to the
+// ec2InstanceSlots table when InstanceSlots is not set explicitly.
+func (s ec2SpotFleetLaunchSpec) slots() int {
+	if s.InstanceSlots != nil {
+		return *s.InstanceSlots
+	}
+	return s.InstanceType.Slots()
+}
+
 var defaultAWSImageID = map[string]string{
 	"ap-northeast-1": "ami-07be463fe74180d9c",
 	"ap-northeast-2": "ami-0bc9dc1a714ff6d82",
@@ -149,22 +307,166 @@ func validateInstanceTypeSlots(c AWSClusterConfig) error {
 		"the ec2 'instance_type' must be one of types: %s", strings.Join(strs, ", "))
 }
 
+// validateSpotFleetLaunchSpecSlots applies the same instance_type/instance_slots check that
+// validateInstanceTypeSlots applies to a single-instance config to one spot fleet launch spec, so
+// a typo'd or unknown instance type doesn't silently resolve to zero slots.
+func validateSpotFleetLaunchSpecSlots(spec ec2SpotFleetLaunchSpec) error {
+	instanceType := spec.InstanceType
+	if _, ok := ec2InstanceSlots[instanceType]; ok {
+		return nil
+	}
+
+	instanceSlots := spec.InstanceSlots
+	if instanceSlots != nil {
+		if *instanceSlots < 0 {
+			return errors.Errorf(
+				"ec2 'spot_fleet.launch_specifications[].instance_slots' must be greater than or " +
+					"equal to 0")
+		}
+		ec2InstanceSlots[instanceType] = *instanceSlots
+		return nil
+	}
+
+	strs := make([]string, 0, len(ec2InstanceSlots))
+	for t := range ec2InstanceSlots {
+		strs = append(strs, t.name())
+	}
+	return errors.Errorf("Either ec2 'spot_fleet.launch_specifications[].instance_type' and "+
+		"'instance_slots' must be specified or 'instance_type' must be one of types: %s",
+		strings.Join(strs, ", "))
+}
+
 // Validate implements the check.Validatable interface.
 func (c AWSClusterConfig) Validate() []error {
 	var spotPriceIsNotValidNumberErr error
 	if c.SpotEnabled && c.SpotMaxPrice != spotPriceNotSetPlaceholder {
 		spotPriceIsNotValidNumberErr = validateMaxSpotPrice(c.SpotMaxPrice)
 	}
-	return []error{
+	errs := []error{
 		check.GreaterThan(len(c.SSHKeyName), 0, "ec2 key name must be non-empty"),
 		check.GreaterThanOrEqualTo(c.RootVolumeSize, 100, "ec2 root volume size must be >= 100"),
 		spotPriceIsNotValidNumberErr,
-		validateInstanceTypeSlots(c),
 	}
+	if c.SpotFleet != nil {
+		errs = append(errs, validateSpotFleet(c))
+	} else {
+		errs = append(errs, validateInstanceTypeSlots(c))
+	}
+	if c.LaunchTemplate != nil {
+		errs = append(errs, validateLaunchTemplate(*c.LaunchTemplate))
+	}
+	return errs
+}
+
+// hasInlineFields reports whether any of the inline-override fields are set.
+func (t ec2LaunchTemplate) hasInlineFields() bool {
+	return len(t.BlockDeviceMappings) > 0 || len(t.UserDataExtra) > 0 ||
+		t.MetadataOptions != nil || t.CreditSpecification != nil ||
+		len(t.ElasticGpuSpecifications) > 0
+}
+
+func validateLaunchTemplate(t ec2LaunchTemplate) error {
+	if t.usesReference() {
+		if t.hasInlineFields() {
+			return errors.Errorf(
+				"ec2 'launch_template' must set exactly one of 'launch_template_id' or the " +
+					"inline override fields, not both")
+		}
+		return nil
+	}
+	if !t.hasInlineFields() {
+		return errors.Errorf(
+			"ec2 'launch_template' must set exactly one of 'launch_template_id' or the " +
+				"inline override fields")
+	}
+	for _, m := range t.BlockDeviceMappings {
+		if err := check.GreaterThanOrEqualTo(
+			m.VolumeSize, 0, "ec2 'launch_template.block_device_mappings[].volume_size' "+
+				"must be >= 0",
+		); err != nil {
+			return err
+		}
+	}
+	if t.MetadataOptions != nil && !t.MetadataOptions.HTTPTokens.valid() {
+		return errors.Errorf(
+			"ec2 'launch_template.metadata_options.http_tokens' must be one of optional, "+
+				"required, got %q", t.MetadataOptions.HTTPTokens)
+	}
+	if t.CreditSpecification != nil && !t.CreditSpecification.CPUCredits.valid() {
+		return errors.Errorf(
+			"ec2 'launch_template.credit_specification.cpu_credits' must be one of standard, "+
+				"unlimited, got %q", t.CreditSpecification.CPUCredits)
+	}
+	return nil
+}
+
+func validateSpotFleet(c AWSClusterConfig) error {
+	f := c.SpotFleet
+	if !f.AllocationStrategy.valid() {
+		return errors.Errorf(
+			"ec2 'spot_fleet.allocation_strategy' must be one of lowestPrice, diversified, "+
+				"capacityOptimized, got %q", f.AllocationStrategy)
+	}
+	if len(f.IamFleetRoleArn) == 0 {
+		return errors.Errorf("ec2 'spot_fleet.iam_fleet_role_arn' must be non-empty")
+	}
+	if len(f.LaunchSpecifications) == 0 {
+		return errors.Errorf("ec2 'spot_fleet.launch_specifications' must be non-empty")
+	}
+	for _, spec := range f.LaunchSpecifications {
+		if len(spec.MaxPrice) > 0 {
+			if err := validateMaxSpotPrice(spec.MaxPrice); err != nil {
+				return err
+			}
+		}
+		if err := validateSpotFleetLaunchSpecSlots(spec); err != nil {
+			return err
+		}
+	}
+	if _, err := fleetSlotType(f.LaunchSpecifications); err != nil {
+		return err
+	}
+	return nil
+}
+
+// fleetSlotType determines the slot type shared by every launch spec in a spot fleet, returning
+// an error if the fleet mixes CUDA and CPU instance types.
+func fleetSlotType(specs []ec2SpotFleetLaunchSpec) (device.Type, error) {
+	var sawCUDA, sawCPU bool
+	for _, spec := range specs {
+		if spec.slots() > 0 {
+			sawCUDA = true
+		} else {
+			sawCPU = true
+		}
+		if sawCUDA && sawCPU {
+			return device.ZeroSlot, errors.Errorf(
+				"ec2 'spot_fleet.launch_specifications' cannot mix CUDA and non-CUDA instance types")
+		}
+	}
+	if sawCUDA {
+		return device.CUDA, nil
+	}
+	return device.ZeroSlot, nil
 }
 
-// SlotsPerInstance returns the number of slots per instance.
+// SlotsPerInstance returns the number of slots per instance. For a spot fleet with heterogeneous
+// launch specs, it returns the smallest slot count across all specs, since that is the worst-case
+// number of slots any one instance EC2 launches for the fleet will provide.
 func (c AWSClusterConfig) SlotsPerInstance() int {
+	if c.SpotFleet != nil {
+		slots := -1
+		for _, spec := range c.SpotFleet.LaunchSpecifications {
+			if specSlots := spec.slots(); slots == -1 || specSlots < slots {
+				slots = specSlots
+			}
+		}
+		if slots == -1 {
+			slots = 0
+		}
+		return slots
+	}
+
 	slots := c.InstanceType.Slots()
 	if slots == 0 && c.CPUSlotsAllowed {
 		slots = 1
@@ -175,6 +477,17 @@ func (c AWSClusterConfig) SlotsPerInstance() int {
 
 // SlotType returns the type of the slot.
 func (c AWSClusterConfig) SlotType() device.Type {
+	if c.SpotFleet != nil {
+		slotType, err := fleetSlotType(c.SpotFleet.LaunchSpecifications)
+		if err != nil || slotType == device.ZeroSlot {
+			if c.CPUSlotsAllowed {
+				return device.CPU
+			}
+			return slotType
+		}
+		return slotType
+	}
+
 	slots := c.InstanceType.Slots()
 	if slots > 0 {
 		return device.CUDA
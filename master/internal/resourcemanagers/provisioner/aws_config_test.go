@@ -0,0 +1,90 @@
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/determined-ai/determined/master/pkg/device"
+)
+
+func TestFleetSlotTypeRejectsMixedFleet(t *testing.T) {
+	specs := []ec2SpotFleetLaunchSpec{
+		{InstanceType: "p3.2xlarge"},
+		{InstanceType: "t3.medium"},
+	}
+	_, err := fleetSlotType(specs)
+	require.Error(t, err)
+}
+
+func TestFleetSlotTypeAllCUDA(t *testing.T) {
+	specs := []ec2SpotFleetLaunchSpec{
+		{InstanceType: "p3.2xlarge"},
+		{InstanceType: "p3.8xlarge"},
+	}
+	slotType, err := fleetSlotType(specs)
+	require.NoError(t, err)
+	require.Equal(t, device.CUDA, slotType)
+}
+
+func TestFleetSlotTypeAllCPU(t *testing.T) {
+	specs := []ec2SpotFleetLaunchSpec{
+		{InstanceType: "t3.medium"},
+		{InstanceType: "c5.large"},
+	}
+	slotType, err := fleetSlotType(specs)
+	require.NoError(t, err)
+	require.Equal(t, device.ZeroSlot, slotType)
+}
+
+func validSpotFleetConfig() AWSClusterConfig {
+	return AWSClusterConfig{
+		SpotFleet: &ec2SpotFleetConfig{
+			IamFleetRoleArn:    "arn:aws:iam::1234:role/fleet",
+			AllocationStrategy: ec2SpotFleetLowestPrice,
+			LaunchSpecifications: []ec2SpotFleetLaunchSpec{
+				{InstanceType: "p3.2xlarge"},
+			},
+		},
+	}
+}
+
+func TestValidateSpotFleetRejectsBadMaxPrice(t *testing.T) {
+	c := validSpotFleetConfig()
+	c.SpotFleet.LaunchSpecifications[0].MaxPrice = "not-a-number"
+	require.Error(t, validateSpotFleet(c))
+}
+
+func TestValidateSpotFleetAcceptsValidMaxPrice(t *testing.T) {
+	c := validSpotFleetConfig()
+	c.SpotFleet.LaunchSpecifications[0].MaxPrice = "1.50"
+	require.NoError(t, validateSpotFleet(c))
+}
+
+func TestValidateSpotFleetRejectsUnknownAllocationStrategy(t *testing.T) {
+	c := validSpotFleetConfig()
+	c.SpotFleet.AllocationStrategy = "not-a-strategy"
+	require.Error(t, validateSpotFleet(c))
+}
+
+func TestValidateSpotFleetRejectsUnknownInstanceTypeWithoutSlots(t *testing.T) {
+	c := validSpotFleetConfig()
+	c.SpotFleet.LaunchSpecifications[0].InstanceType = "made.up.type"
+	require.Error(t, validateSpotFleet(c))
+}
+
+func TestValidateSpotFleetRejectsNegativeInstanceSlots(t *testing.T) {
+	c := validSpotFleetConfig()
+	slots := -1
+	c.SpotFleet.LaunchSpecifications[0].InstanceType = "made.up.type"
+	c.SpotFleet.LaunchSpecifications[0].InstanceSlots = &slots
+	require.Error(t, validateSpotFleet(c))
+}
+
+func TestValidateSpotFleetAcceptsUnknownInstanceTypeWithSlots(t *testing.T) {
+	c := validSpotFleetConfig()
+	slots := 4
+	c.SpotFleet.LaunchSpecifications[0].InstanceType = "made.up.type"
+	c.SpotFleet.LaunchSpecifications[0].InstanceSlots = &slots
+	require.NoError(t, validateSpotFleet(c))
+}
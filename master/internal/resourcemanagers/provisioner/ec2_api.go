@@ -0,0 +1,48 @@
+package provisioner
+
+import (
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// EC2API declares the subset of the EC2 client the provisioner depends on, so that scale-up,
+// scale-down, and agent-discovery logic can be unit-tested against a fake without real AWS
+// credentials or network access, and so the concrete SDK (aws-sdk-go vs. aws-sdk-go-v2) can be
+// swapped without touching the provisioner itself.
+type EC2API interface {
+	RunInstances(*ec2.RunInstancesInput) (*ec2.Reservation, error)
+	TerminateInstances(*ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error)
+	DescribeInstances(*ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error)
+	CreateTags(*ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error)
+
+	RequestSpotInstances(
+		*ec2.RequestSpotInstancesInput,
+	) (*ec2.RequestSpotInstancesOutput, error)
+	CancelSpotInstanceRequests(
+		*ec2.CancelSpotInstanceRequestsInput,
+	) (*ec2.CancelSpotInstanceRequestsOutput, error)
+	DescribeSpotInstanceRequests(
+		*ec2.DescribeSpotInstanceRequestsInput,
+	) (*ec2.DescribeSpotInstanceRequestsOutput, error)
+
+	RequestSpotFleet(*ec2.RequestSpotFleetInput) (*ec2.RequestSpotFleetOutput, error)
+	ModifySpotFleetRequest(
+		*ec2.ModifySpotFleetRequestInput,
+	) (*ec2.ModifySpotFleetRequestOutput, error)
+	CancelSpotFleetRequests(
+		*ec2.CancelSpotFleetRequestsInput,
+	) (*ec2.CancelSpotFleetRequestsOutput, error)
+}
+
+// ec2APIFactory builds an EC2API client for the configured region. defaultEC2APIFactory is
+// newEC2Client (backed by the real AWS SDK); testutil.FakeEC2 also satisfies EC2API.
+type ec2APIFactory func(c AWSClusterConfig) (EC2API, error)
+
+var defaultEC2APIFactory ec2APIFactory = newEC2Client
+
+func newEC2Client(c AWSClusterConfig) (EC2API, error) {
+	sess, err := getEC2Sess(c.Region)
+	if err != nil {
+		return nil, err
+	}
+	return ec2.New(sess), nil
+}
@@ -0,0 +1,489 @@
+// Package testutil provides an in-memory fake of the EC2 API for exercising the provisioner's
+// scale-up/scale-down decisions without real AWS credentials or network access.
+package testutil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+)
+
+// InjectedError, when non-nil, is returned by the next matching FakeEC2 call instead of running
+// the fake's normal logic. It lets tests simulate throttling, InsufficientInstanceCapacity, and
+// spot interruption without a real EC2 endpoint.
+type InjectedError struct {
+	// Op restricts the injected error to one EC2 operation, e.g. "RunInstances". An empty Op
+	// matches every operation.
+	Op  string
+	Err error
+}
+
+// FakeEC2 is an in-memory stand-in for provisioner.EC2API. It tracks instances and spot/fleet
+// requests well enough to drive the provisioner's scale-up, scale-down, and agent-discovery
+// logic in tests.
+type FakeEC2 struct {
+	mu sync.Mutex
+
+	nextID      int
+	nextSpotID  int
+	nextFleetID int
+	instances   map[string]*ec2.Instance
+
+	spotRequests  map[string]*ec2.SpotInstanceRequest
+	fleetRequests map[string]*fakeFleet
+
+	errorQueue []InjectedError
+}
+
+type fakeFleet struct {
+	targetCapacity float64
+	launchSpecs    []*ec2.SpotFleetLaunchSpecification
+
+	instanceIDs []string
+	// instanceWeights tracks each backing instance's WeightedCapacity, so the fleet's current
+	// capacity can be computed by summing weights rather than counting instances (the two differ
+	// whenever a launch spec sets a WeightedCapacity != 1).
+	instanceWeights map[string]float64
+}
+
+// capacity returns the fleet's current weighted capacity: the sum of every backing instance's
+// WeightedCapacity.
+func (fleet *fakeFleet) capacity() float64 {
+	var total float64
+	for _, w := range fleet.instanceWeights {
+		total += w
+	}
+	return total
+}
+
+// NewFakeEC2 returns an empty fake with no instances or outstanding requests.
+func NewFakeEC2() *FakeEC2 {
+	return &FakeEC2{
+		instances:     make(map[string]*ec2.Instance),
+		spotRequests:  make(map[string]*ec2.SpotInstanceRequest),
+		fleetRequests: make(map[string]*fakeFleet),
+	}
+}
+
+// InjectError queues an error to be returned by the next call matching its Op (or any call, if
+// Op is empty). Errors are consumed in FIFO order.
+func (f *FakeEC2) InjectError(e InjectedError) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errorQueue = append(f.errorQueue, e)
+}
+
+// takeInjectedError consumes and returns the first queued error matching op, if any.
+func (f *FakeEC2) takeInjectedError(op string) error {
+	for i, e := range f.errorQueue {
+		if e.Op == "" || e.Op == op {
+			f.errorQueue = append(f.errorQueue[:i], f.errorQueue[i+1:]...)
+			return e.Err
+		}
+	}
+	return nil
+}
+
+func (f *FakeEC2) newInstanceID() string {
+	f.nextID++
+	return fmt.Sprintf("i-fake%08d", f.nextID)
+}
+
+func (f *FakeEC2) newSpotRequestID() string {
+	f.nextSpotID++
+	return fmt.Sprintf("sir-fake%08d", f.nextSpotID)
+}
+
+func (f *FakeEC2) newFleetID() string {
+	f.nextFleetID++
+	return fmt.Sprintf("sfr-fake%08d-0000-0000-0000-000000000000", f.nextFleetID)
+}
+
+// tagsFromSpecs collects the tags meant for resourceType (e.g. "instance") out of a
+// RunInstances/RequestSpotInstances-style TagSpecifications list.
+func tagsFromSpecs(specs []*ec2.TagSpecification, resourceType string) []*ec2.Tag {
+	var tags []*ec2.Tag
+	for _, spec := range specs {
+		if aws.StringValue(spec.ResourceType) == resourceType {
+			tags = append(tags, spec.Tags...)
+		}
+	}
+	return tags
+}
+
+// instanceMatchesFilters reports whether inst satisfies every filter in filters, the same way EC2
+// ANDs filter names together and ORs the values within one filter. Only the subset of filter names
+// the provisioner's agent-discovery path relies on ("tag:<key>" and "instance-state-name") are
+// implemented; an unrecognized filter name matches nothing, so a test exercising it fails loudly
+// instead of silently returning every instance.
+func instanceMatchesFilters(inst *ec2.Instance, filters []*ec2.Filter) bool {
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+		switch {
+		case strings.HasPrefix(name, "tag:"):
+			key := strings.TrimPrefix(name, "tag:")
+			if !instanceHasTagValue(inst, key, filter.Values) {
+				return false
+			}
+		case name == "instance-state-name":
+			if !stringValueMatches(aws.StringValue(inst.State.Name), filter.Values) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func instanceHasTagValue(inst *ec2.Instance, key string, values []*string) bool {
+	for _, tag := range inst.Tags {
+		if aws.StringValue(tag.Key) == key && stringValueMatches(aws.StringValue(tag.Value), values) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringValueMatches(s string, values []*string) bool {
+	for _, v := range values {
+		if aws.StringValue(v) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RunInstances launches exactly MinCount instances (MaxCount is ignored) and returns them as a
+// single reservation.
+func (f *FakeEC2) RunInstances(in *ec2.RunInstancesInput) (*ec2.Reservation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeInjectedError("RunInstances"); err != nil {
+		return nil, err
+	}
+
+	count := aws.Int64Value(in.MinCount)
+	tags := tagsFromSpecs(in.TagSpecifications, ec2.ResourceTypeInstance)
+	var instances []*ec2.Instance
+	for i := int64(0); i < count; i++ {
+		id := f.newInstanceID()
+		inst := &ec2.Instance{
+			InstanceId:   aws.String(id),
+			InstanceType: in.InstanceType,
+			State:        &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			Tags:         tags,
+		}
+		f.instances[id] = inst
+		instances = append(instances, inst)
+	}
+	return &ec2.Reservation{Instances: instances}, nil
+}
+
+// TerminateInstances marks the given instance IDs as terminated.
+func (f *FakeEC2) TerminateInstances(
+	in *ec2.TerminateInstancesInput,
+) (*ec2.TerminateInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeInjectedError("TerminateInstances"); err != nil {
+		return nil, err
+	}
+
+	var changes []*ec2.InstanceStateChange
+	for _, id := range in.InstanceIds {
+		inst, ok := f.instances[aws.StringValue(id)]
+		if !ok {
+			return nil, errors.Errorf("unknown instance %s", aws.StringValue(id))
+		}
+		inst.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameTerminated)}
+		changes = append(changes, &ec2.InstanceStateChange{
+			InstanceId:   id,
+			CurrentState: inst.State,
+		})
+	}
+	return &ec2.TerminateInstancesOutput{TerminatingInstances: changes}, nil
+}
+
+// DescribeInstances returns every tracked instance matching the request's instance ID filter and
+// Filters (e.g. "tag:<key>"), so tests can exercise the agent-discovery path the same way it
+// queries real EC2: by the fleet's TagSpecifications rather than by ID.
+func (f *FakeEC2) DescribeInstances(
+	in *ec2.DescribeInstancesInput,
+) (*ec2.DescribeInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeInjectedError("DescribeInstances"); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool, len(in.InstanceIds))
+	for _, id := range in.InstanceIds {
+		ids[aws.StringValue(id)] = true
+	}
+
+	var instances []*ec2.Instance
+	for id, inst := range f.instances {
+		if len(ids) > 0 && !ids[id] {
+			continue
+		}
+		if !instanceMatchesFilters(inst, in.Filters) {
+			continue
+		}
+		instances = append(instances, inst)
+	}
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{Instances: instances}},
+	}, nil
+}
+
+// CreateTags tags the given instances in place; resource IDs that are not tracked instances (such
+// as spot or fleet request IDs) are skipped.
+func (f *FakeEC2) CreateTags(in *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeInjectedError("CreateTags"); err != nil {
+		return nil, err
+	}
+
+	for _, id := range in.Resources {
+		inst, ok := f.instances[aws.StringValue(id)]
+		if !ok {
+			continue
+		}
+		inst.Tags = append(inst.Tags, in.Tags...)
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// RequestSpotInstances fulfills every requested spot instance immediately: it launches a backing
+// instance for each and returns the requests in the "active" state. Real EC2 can leave a request
+// "open" for a while if capacity is tight; the fake skips that since spot-interruption handling is
+// tested via InjectError + TerminateInstances instead of a pending state machine.
+func (f *FakeEC2) RequestSpotInstances(
+	in *ec2.RequestSpotInstancesInput,
+) (*ec2.RequestSpotInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeInjectedError("RequestSpotInstances"); err != nil {
+		return nil, err
+	}
+
+	count := aws.Int64Value(in.InstanceCount)
+	if count == 0 {
+		count = 1
+	}
+	instanceTags := tagsFromSpecs(in.TagSpecifications, ec2.ResourceTypeInstance)
+	requestTags := tagsFromSpecs(in.TagSpecifications, ec2.ResourceTypeSpotInstancesRequest)
+
+	var requests []*ec2.SpotInstanceRequest
+	for i := int64(0); i < count; i++ {
+		instanceID := f.newInstanceID()
+		f.instances[instanceID] = &ec2.Instance{
+			InstanceId:   aws.String(instanceID),
+			InstanceType: in.LaunchSpecification.InstanceType,
+			State:        &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			Tags:         instanceTags,
+		}
+
+		reqID := f.newSpotRequestID()
+		sir := &ec2.SpotInstanceRequest{
+			SpotInstanceRequestId: aws.String(reqID),
+			State:                 aws.String(ec2.SpotInstanceStateActive),
+			InstanceId:            aws.String(instanceID),
+			SpotPrice:             in.SpotPrice,
+			Tags:                  requestTags,
+		}
+		f.spotRequests[reqID] = sir
+		requests = append(requests, sir)
+	}
+	return &ec2.RequestSpotInstancesOutput{SpotInstanceRequests: requests}, nil
+}
+
+// CancelSpotInstanceRequests marks the given spot requests as cancelled. It does not terminate the
+// backing instances, matching real EC2: a caller that wants the instances gone too still has to
+// call TerminateInstances itself.
+func (f *FakeEC2) CancelSpotInstanceRequests(
+	in *ec2.CancelSpotInstanceRequestsInput,
+) (*ec2.CancelSpotInstanceRequestsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeInjectedError("CancelSpotInstanceRequests"); err != nil {
+		return nil, err
+	}
+
+	var cancelled []*ec2.CancelledSpotInstanceRequest
+	for _, id := range in.SpotInstanceRequestIds {
+		reqID := aws.StringValue(id)
+		sir, ok := f.spotRequests[reqID]
+		if !ok {
+			return nil, errors.Errorf("unknown spot instance request %s", reqID)
+		}
+		sir.State = aws.String(ec2.SpotInstanceStateCancelled)
+		cancelled = append(cancelled, &ec2.CancelledSpotInstanceRequest{
+			SpotInstanceRequestId: aws.String(reqID),
+			State:                 sir.State,
+		})
+	}
+	return &ec2.CancelSpotInstanceRequestsOutput{CancelledSpotInstanceRequests: cancelled}, nil
+}
+
+// DescribeSpotInstanceRequests returns every tracked spot request matching the request's ID
+// filter, or every tracked request if none was given.
+func (f *FakeEC2) DescribeSpotInstanceRequests(
+	in *ec2.DescribeSpotInstanceRequestsInput,
+) (*ec2.DescribeSpotInstanceRequestsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeInjectedError("DescribeSpotInstanceRequests"); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool, len(in.SpotInstanceRequestIds))
+	for _, id := range in.SpotInstanceRequestIds {
+		ids[aws.StringValue(id)] = true
+	}
+
+	var requests []*ec2.SpotInstanceRequest
+	for id, sir := range f.spotRequests {
+		if len(ids) == 0 || ids[id] {
+			requests = append(requests, sir)
+		}
+	}
+	return &ec2.DescribeSpotInstanceRequestsOutput{SpotInstanceRequests: requests}, nil
+}
+
+// RequestSpotFleet fills the requested target capacity immediately, round-robining across the
+// fleet's launch specifications the same way EC2's lowestPrice/diversified strategies spread a
+// fleet across instance types, and tags each backing instance from that launch spec's
+// TagSpecifications so agent-discovery filters can still find them.
+func (f *FakeEC2) RequestSpotFleet(
+	in *ec2.RequestSpotFleetInput,
+) (*ec2.RequestSpotFleetOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeInjectedError("RequestSpotFleet"); err != nil {
+		return nil, err
+	}
+
+	cfg := in.SpotFleetRequestConfig
+	fleetID := f.newFleetID()
+	fleet := &fakeFleet{
+		targetCapacity:  float64(aws.Int64Value(cfg.TargetCapacity)),
+		launchSpecs:     cfg.LaunchSpecifications,
+		instanceWeights: make(map[string]float64),
+	}
+	f.fillFleet(fleet, fleet.targetCapacity)
+	f.fleetRequests[fleetID] = fleet
+	return &ec2.RequestSpotFleetOutput{SpotFleetRequestId: aws.String(fleetID)}, nil
+}
+
+// fillFleet launches instances, round-robining across fleet.launchSpecs, until fleet's weighted
+// capacity (by WeightedCapacity, defaulting to 1 per instance) reaches targetCapacity.
+func (f *FakeEC2) fillFleet(fleet *fakeFleet, targetCapacity float64) {
+	if len(fleet.launchSpecs) == 0 {
+		return
+	}
+	have := fleet.capacity()
+	for i := 0; have < targetCapacity; i++ {
+		spec := fleet.launchSpecs[i%len(fleet.launchSpecs)]
+		weight := aws.Float64Value(spec.WeightedCapacity)
+		if weight == 0 {
+			weight = 1
+		}
+		id := f.newInstanceID()
+		f.instances[id] = &ec2.Instance{
+			InstanceId:   aws.String(id),
+			InstanceType: spec.InstanceType,
+			State:        &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+			Tags:         tagsFromSpecs(spec.TagSpecifications, ec2.ResourceTypeInstance),
+		}
+		fleet.instanceIDs = append(fleet.instanceIDs, id)
+		fleet.instanceWeights[id] = weight
+		have += weight
+	}
+}
+
+// shrinkFleet terminates instances, most-recently-launched first, until fleet's weighted capacity
+// drops to targetCapacity or no instances remain. It mirrors EC2's ExcessCapacityTerminationPolicy:
+// the default ("default", and an unset policy) terminates excess capacity; "noTermination" leaves
+// the fleet over-provisioned instead.
+func (f *FakeEC2) shrinkFleet(fleet *fakeFleet, targetCapacity float64, policy string) {
+	if policy == ec2.ExcessCapacityTerminationPolicyNoTermination {
+		return
+	}
+	for fleet.capacity() > targetCapacity && len(fleet.instanceIDs) > 0 {
+		last := len(fleet.instanceIDs) - 1
+		id := fleet.instanceIDs[last]
+		fleet.instanceIDs = fleet.instanceIDs[:last]
+		delete(fleet.instanceWeights, id)
+		if inst, ok := f.instances[id]; ok {
+			inst.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameTerminated)}
+		}
+	}
+}
+
+// ModifySpotFleetRequest adjusts a fleet's target capacity: launching additional instances with
+// the fleet's original launch specs if the new target is higher, or terminating excess instances
+// if it's lower (subject to ExcessCapacityTerminationPolicy).
+func (f *FakeEC2) ModifySpotFleetRequest(
+	in *ec2.ModifySpotFleetRequestInput,
+) (*ec2.ModifySpotFleetRequestOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeInjectedError("ModifySpotFleetRequest"); err != nil {
+		return nil, err
+	}
+
+	fleetID := aws.StringValue(in.SpotFleetRequestId)
+	fleet, ok := f.fleetRequests[fleetID]
+	if !ok {
+		return nil, errors.Errorf("unknown spot fleet request %s", fleetID)
+	}
+	fleet.targetCapacity = float64(aws.Int64Value(in.TargetCapacity))
+	if fleet.targetCapacity < fleet.capacity() {
+		f.shrinkFleet(fleet, fleet.targetCapacity, aws.StringValue(in.ExcessCapacityTerminationPolicy))
+	} else {
+		f.fillFleet(fleet, fleet.targetCapacity)
+	}
+	return &ec2.ModifySpotFleetRequestOutput{Return: aws.Bool(true)}, nil
+}
+
+// CancelSpotFleetRequests removes the given fleets, terminating their backing instances when
+// TerminateInstances is set.
+func (f *FakeEC2) CancelSpotFleetRequests(
+	in *ec2.CancelSpotFleetRequestsInput,
+) (*ec2.CancelSpotFleetRequestsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeInjectedError("CancelSpotFleetRequests"); err != nil {
+		return nil, err
+	}
+
+	var successful []*ec2.CancelSpotFleetRequestsSuccessItem
+	for _, id := range in.SpotFleetRequestIds {
+		fleetID := aws.StringValue(id)
+		fleet, ok := f.fleetRequests[fleetID]
+		if !ok {
+			return nil, errors.Errorf("unknown spot fleet request %s", fleetID)
+		}
+		if aws.BoolValue(in.TerminateInstances) {
+			for _, instID := range fleet.instanceIDs {
+				if inst, ok := f.instances[instID]; ok {
+					inst.State = &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameTerminated)}
+				}
+			}
+		}
+		delete(f.fleetRequests, fleetID)
+		successful = append(successful, &ec2.CancelSpotFleetRequestsSuccessItem{
+			SpotFleetRequestId: aws.String(fleetID),
+			CurrentSpotFleetRequestState: aws.String(
+				ec2.BatchStateCancelledTerminating),
+		})
+	}
+	return &ec2.CancelSpotFleetRequestsOutput{SuccessfulFleetRequests: successful}, nil
+}
@@ -0,0 +1,220 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeEC2RequestSpotInstancesLaunchesBackingInstances(t *testing.T) {
+	f := NewFakeEC2()
+	out, err := f.RequestSpotInstances(&ec2.RequestSpotInstancesInput{
+		InstanceCount: aws.Int64(2),
+		LaunchSpecification: &ec2.RequestSpotLaunchSpecification{
+			InstanceType: aws.String("p3.2xlarge"),
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, out.SpotInstanceRequests, 2)
+	for _, sir := range out.SpotInstanceRequests {
+		require.Equal(t, ec2.SpotInstanceStateActive, aws.StringValue(sir.State))
+		require.NotEmpty(t, aws.StringValue(sir.InstanceId))
+	}
+
+	described, err := f.DescribeInstances(&ec2.DescribeInstancesInput{})
+	require.NoError(t, err)
+	require.Len(t, described.Reservations[0].Instances, 2)
+}
+
+func TestFakeEC2CancelSpotInstanceRequestsUnknownID(t *testing.T) {
+	f := NewFakeEC2()
+	_, err := f.CancelSpotInstanceRequests(&ec2.CancelSpotInstanceRequestsInput{
+		SpotInstanceRequestIds: []*string{aws.String("sir-doesnotexist")},
+	})
+	require.Error(t, err)
+}
+
+func TestFakeEC2RequestSpotFleetFillsTargetCapacityAndTags(t *testing.T) {
+	f := NewFakeEC2()
+	out, err := f.RequestSpotFleet(&ec2.RequestSpotFleetInput{
+		SpotFleetRequestConfig: &ec2.SpotFleetRequestConfig{
+			TargetCapacity: aws.Int64(3),
+			LaunchSpecifications: []*ec2.SpotFleetLaunchSpecification{
+				{
+					InstanceType: aws.String("p3.2xlarge"),
+					TagSpecifications: []*ec2.TagSpecification{
+						{
+							ResourceType: aws.String(ec2.ResourceTypeInstance),
+							Tags: []*ec2.Tag{
+								{Key: aws.String("managed_by"), Value: aws.String("determined")},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	fleetID := aws.StringValue(out.SpotFleetRequestId)
+	require.NotEmpty(t, fleetID)
+
+	described, err := f.DescribeInstances(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("tag:managed_by"), Values: []*string{aws.String("determined")}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, described.Reservations[0].Instances, 3)
+}
+
+func TestFakeEC2DescribeInstancesFiltersByTag(t *testing.T) {
+	f := NewFakeEC2()
+	_, err := f.RunInstances(&ec2.RunInstancesInput{
+		MinCount:     aws.Int64(1),
+		InstanceType: aws.String("t3.medium"),
+		TagSpecifications: []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String(ec2.ResourceTypeInstance),
+				Tags:         []*ec2.Tag{{Key: aws.String("pool"), Value: aws.String("a")}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	_, err = f.RunInstances(&ec2.RunInstancesInput{
+		MinCount:     aws.Int64(1),
+		InstanceType: aws.String("t3.medium"),
+		TagSpecifications: []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String(ec2.ResourceTypeInstance),
+				Tags:         []*ec2.Tag{{Key: aws.String("pool"), Value: aws.String("b")}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	described, err := f.DescribeInstances(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("tag:pool"), Values: []*string{aws.String("a")}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, described.Reservations[0].Instances, 1)
+}
+
+func TestFakeEC2CancelSpotFleetRequestsTerminatesInstances(t *testing.T) {
+	f := NewFakeEC2()
+	out, err := f.RequestSpotFleet(&ec2.RequestSpotFleetInput{
+		SpotFleetRequestConfig: &ec2.SpotFleetRequestConfig{
+			TargetCapacity: aws.Int64(1),
+			LaunchSpecifications: []*ec2.SpotFleetLaunchSpecification{
+				{InstanceType: aws.String("p3.2xlarge")},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = f.CancelSpotFleetRequests(&ec2.CancelSpotFleetRequestsInput{
+		SpotFleetRequestIds: []*string{out.SpotFleetRequestId},
+		TerminateInstances:  aws.Bool(true),
+	})
+	require.NoError(t, err)
+
+	described, err := f.DescribeInstances(&ec2.DescribeInstancesInput{})
+	require.NoError(t, err)
+	require.Equal(t, ec2.InstanceStateNameTerminated,
+		aws.StringValue(described.Reservations[0].Instances[0].State.Name))
+}
+
+func TestFakeEC2InjectError(t *testing.T) {
+	f := NewFakeEC2()
+	f.InjectError(InjectedError{Op: "RunInstances", Err: errors.New("simulated throttling")})
+	_, err := f.RunInstances(&ec2.RunInstancesInput{MinCount: aws.Int64(1)})
+	require.Error(t, err)
+}
+
+func countByState(t *testing.T, f *FakeEC2, state string) int {
+	t.Helper()
+	described, err := f.DescribeInstances(&ec2.DescribeInstancesInput{})
+	require.NoError(t, err)
+	count := 0
+	for _, inst := range described.Reservations[0].Instances {
+		if aws.StringValue(inst.State.Name) == state {
+			count++
+		}
+	}
+	return count
+}
+
+func weightedFleetSpec() []*ec2.SpotFleetLaunchSpecification {
+	return []*ec2.SpotFleetLaunchSpecification{
+		{InstanceType: aws.String("p3.2xlarge"), WeightedCapacity: aws.Float64(2)},
+	}
+}
+
+func TestFakeEC2ModifySpotFleetRequestScaleUpRespectsWeightedCapacity(t *testing.T) {
+	f := NewFakeEC2()
+	out, err := f.RequestSpotFleet(&ec2.RequestSpotFleetInput{
+		SpotFleetRequestConfig: &ec2.SpotFleetRequestConfig{
+			TargetCapacity:       aws.Int64(4),
+			LaunchSpecifications: weightedFleetSpec(),
+		},
+	})
+	require.NoError(t, err)
+	// 4 / weight(2) == 2 instances.
+	require.Equal(t, 2, countByState(t, f, ec2.InstanceStateNameRunning))
+
+	_, err = f.ModifySpotFleetRequest(&ec2.ModifySpotFleetRequestInput{
+		SpotFleetRequestId: out.SpotFleetRequestId,
+		TargetCapacity:     aws.Int64(8),
+	})
+	require.NoError(t, err)
+	// Scaling from weighted capacity 4 to 8 should add exactly 2 more weight-2 instances (4
+	// total), not over-launch by counting the existing 2 instances as if they were weight 1.
+	require.Equal(t, 4, countByState(t, f, ec2.InstanceStateNameRunning))
+}
+
+func TestFakeEC2ModifySpotFleetRequestScaleDownTerminatesExcessInstances(t *testing.T) {
+	f := NewFakeEC2()
+	out, err := f.RequestSpotFleet(&ec2.RequestSpotFleetInput{
+		SpotFleetRequestConfig: &ec2.SpotFleetRequestConfig{
+			TargetCapacity: aws.Int64(4),
+			LaunchSpecifications: []*ec2.SpotFleetLaunchSpecification{
+				{InstanceType: aws.String("p3.2xlarge")},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, countByState(t, f, ec2.InstanceStateNameRunning))
+
+	_, err = f.ModifySpotFleetRequest(&ec2.ModifySpotFleetRequestInput{
+		SpotFleetRequestId: out.SpotFleetRequestId,
+		TargetCapacity:     aws.Int64(2),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, countByState(t, f, ec2.InstanceStateNameRunning))
+	require.Equal(t, 2, countByState(t, f, ec2.InstanceStateNameTerminated))
+}
+
+func TestFakeEC2ModifySpotFleetRequestNoTerminationPolicyKeepsInstances(t *testing.T) {
+	f := NewFakeEC2()
+	out, err := f.RequestSpotFleet(&ec2.RequestSpotFleetInput{
+		SpotFleetRequestConfig: &ec2.SpotFleetRequestConfig{
+			TargetCapacity: aws.Int64(4),
+			LaunchSpecifications: []*ec2.SpotFleetLaunchSpecification{
+				{InstanceType: aws.String("p3.2xlarge")},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = f.ModifySpotFleetRequest(&ec2.ModifySpotFleetRequestInput{
+		SpotFleetRequestId:              out.SpotFleetRequestId,
+		TargetCapacity:                  aws.Int64(2),
+		ExcessCapacityTerminationPolicy: aws.String(ec2.ExcessCapacityTerminationPolicyNoTermination),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, countByState(t, f, ec2.InstanceStateNameRunning))
+}
@@ -0,0 +1,51 @@
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLaunchTemplateRejectsIDAndInlineTogether(t *testing.T) {
+	tmpl := ec2LaunchTemplate{
+		LaunchTemplateID: "lt-1234",
+		UserDataExtra:    "echo hi",
+	}
+	require.Error(t, validateLaunchTemplate(tmpl))
+}
+
+func TestValidateLaunchTemplateAcceptsReferenceAlone(t *testing.T) {
+	tmpl := ec2LaunchTemplate{LaunchTemplateID: "lt-1234"}
+	require.NoError(t, validateLaunchTemplate(tmpl))
+}
+
+func TestValidateLaunchTemplateRejectsBadCPUCredits(t *testing.T) {
+	tmpl := ec2LaunchTemplate{
+		CreditSpecification: &ec2CreditSpecification{CPUCredits: "bogus"},
+	}
+	require.Error(t, validateLaunchTemplate(tmpl))
+}
+
+func TestValidateLaunchTemplateRejectsBadHTTPTokens(t *testing.T) {
+	tmpl := ec2LaunchTemplate{
+		MetadataOptions: &ec2MetadataOptions{HTTPTokens: "bogus"},
+	}
+	require.Error(t, validateLaunchTemplate(tmpl))
+}
+
+func TestExpandedCustomTagsSubstitutesVariables(t *testing.T) {
+	c := AWSClusterConfig{
+		CustomTags: []*ec2Tag{
+			{Key: "pool", Value: "{pool_name}"},
+			{Key: "user", Value: "owner={determined_user}"},
+		},
+	}
+	tags := c.expandedCustomTags(customTagTemplateVars{
+		InstanceID:     "i-abc",
+		PoolName:       "default",
+		DeterminedUser: "alice",
+	})
+	require.Len(t, tags, 2)
+	require.Equal(t, "default", tags[0].Value)
+	require.Equal(t, "owner=alice", tags[1].Value)
+}